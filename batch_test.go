@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunBatch_SplitsOnNUL(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+	pathA := write("a.go", "package p\n\nfunc A() {}\n")
+	pathB := write("b.go", "package p\n\nfunc B() {}\n")
+
+	stdinR, stdinW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe (stdin): %v", err)
+	}
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe (stdout): %v", err)
+	}
+
+	prevStdin, prevStdout, prevFormat := os.Stdin, os.Stdout, *outputFormat
+	os.Stdin, os.Stdout, *outputFormat = stdinR, stdoutW, "jsonl"
+	defer func() { os.Stdin, os.Stdout, *outputFormat = prevStdin, prevStdout, prevFormat }()
+
+	go func() {
+		stdinW.WriteString(pathA + "\x00" + pathB + "\x00")
+		stdinW.Close()
+	}()
+
+	done := make(chan error, 1)
+	go func() {
+		fset := token.NewFileSet()
+		done <- runBatch(fset, parser.ParseComments)
+	}()
+
+	if err := <-done; err != nil {
+		t.Fatalf("runBatch: %v", err)
+	}
+	stdoutW.Close()
+
+	var roots []string
+	scanner := bufio.NewScanner(stdoutR)
+	for scanner.Scan() {
+		var rec jsonlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("unmarshal line %q: %v", scanner.Text(), err)
+		}
+		if rec.Parent == -1 {
+			roots = append(roots, rec.File)
+		}
+	}
+
+	if len(roots) != 2 {
+		t.Fatalf("expected one root record per batched file, got %d: %v", len(roots), roots)
+	}
+	if roots[0] != pathA || roots[1] != pathB {
+		t.Errorf("roots = %v, want [%s %s] in order", roots, pathA, pathB)
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOutlineDir_RespectsBuildTags(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name, content string) {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	write("a.go", "package pkg\n\nfunc A() {}\n")
+	write("b.go", "//go:build ignore\n\npackage pkg\n\nfunc B() {}\n")
+
+	// c.go exists only in the -modified overlay, never written to disk, to
+	// cover the editor-sends-an-unsaved-buffer case: WalkDir can't visit it
+	// on its own, and MatchFile must consult the overlay content rather than
+	// failing to open a nonexistent file.
+	archive := buildOverlayArchive(map[string]string{
+		filepath.Join(dir, "c.go"): "package pkg\n\nfunc C() {}\n",
+		filepath.Join(dir, "d.go"): "//go:build ignore\n\npackage pkg\n\nfunc D() {}\n",
+	})
+
+	prevModified, prevTags := *modified, *tags
+	*modified, *tags = true, ""
+	defer func() { *modified, *tags = prevModified, prevTags }()
+
+	var pkg *Declaration
+	var errs []outlineError
+	withStdin(t, archive, func() {
+		fset := token.NewFileSet()
+		var err error
+		pkg, errs, err = outlineDir(fset, dir, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("outlineDir: %v", err)
+		}
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %+v", errs)
+	}
+
+	var labels []string
+	for _, child := range pkg.Children {
+		labels = append(labels, child.Label)
+	}
+
+	if len(labels) != 2 || labels[0] != "A" || labels[1] != "C" {
+		t.Errorf("expected A and overlay-only C (b.go and d.go excluded by their go:build ignore constraint), got %v", labels)
+	}
+}
+
+func TestOutlineDir_ReportsMatchFileErrors(t *testing.T) {
+	dir := t.TempDir()
+	// MatchFile needs to read the file's leading comment to check for a
+	// //go:build constraint; an unreadable file should surface as an
+	// outlineError rather than aborting the whole walk.
+	path := filepath.Join(dir, "unreadable.go")
+	if err := os.WriteFile(path, []byte("package pkg\n"), 0o000); err != nil {
+		t.Fatalf("write unreadable.go: %v", err)
+	}
+	if os.Geteuid() == 0 {
+		t.Skip("running as root can read files regardless of mode")
+	}
+
+	prevModified, prevTags := *modified, *tags
+	*modified, *tags = false, ""
+	defer func() { *modified, *tags = prevModified, prevTags }()
+
+	fset := token.NewFileSet()
+	_, errs, err := outlineDir(fset, dir, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("outlineDir: %v", err)
+	}
+	if len(errs) != 1 || errs[0].File != path {
+		t.Errorf("expected one outlineError for %s, got %+v", path, errs)
+	}
+}
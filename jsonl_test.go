@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteJSONL_ParentIDs(t *testing.T) {
+	child := Declaration{Label: "Name", Type: "field"}
+	pkg := &Declaration{Label: "p", Type: "package", Children: []Declaration{child}}
+
+	var buf bytes.Buffer
+	writeJSONL(&buf, pkg, nil)
+
+	var records []jsonlRecord
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var rec jsonlRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			t.Fatalf("unmarshal line %q: %v", scanner.Text(), err)
+		}
+		records = append(records, rec)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records (package + field), got %d: %+v", len(records), records)
+	}
+
+	root, child0 := records[0], records[1]
+
+	if root.Parent != -1 || root.Label != "p" {
+		t.Errorf("unexpected root record: %+v", root)
+	}
+	if child0.Parent != root.ID || child0.Label != "Name" {
+		t.Errorf("unexpected child record: %+v, want parent %d", child0, root.ID)
+	}
+	if child0.ID == root.ID {
+		t.Errorf("child and root share the same id: %d", root.ID)
+	}
+}
+
+func TestWriteJSONL_ErrorsEmittedBeforeDeclarations(t *testing.T) {
+	pkg := &Declaration{Label: "p", Type: "package"}
+	errs := []outlineError{{File: "broken.go", Msg: "unexpected EOF"}}
+
+	var buf bytes.Buffer
+	writeJSONL(&buf, pkg, errs)
+
+	scanner := bufio.NewScanner(&buf)
+	if !scanner.Scan() {
+		t.Fatal("expected at least one line of output")
+	}
+
+	var first map[string]interface{}
+	if err := json.Unmarshal(scanner.Bytes(), &first); err != nil {
+		t.Fatalf("unmarshal first line: %v", err)
+	}
+	if first["msg"] != "unexpected EOF" {
+		t.Errorf("expected the error line first, got %+v", first)
+	}
+}
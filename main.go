@@ -1,14 +1,21 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
 	"flag"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/format"
 	"go/parser"
+	"go/scanner"
 	"go/token"
+	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/3JoB/ulib/json"
 	"golang.org/x/tools/go/buildutil"
@@ -18,28 +25,73 @@ type Declaration struct {
 	Label        string        `json:"label"`
 	Type         string        `json:"type"`
 	ReceiverType string        `json:"receiverType,omitempty"`
+	File         string        `json:"file,omitempty"`
+	Doc          string        `json:"doc,omitempty"`
 	Start        token.Pos     `json:"start"`
 	End          token.Pos     `json:"end"`
+	StartLine    int           `json:"startLine"`
+	StartCol     int           `json:"startCol"`
+	EndLine      int           `json:"endLine"`
+	EndCol       int           `json:"endCol"`
 	Children     []Declaration `json:"children,omitempty"`
 }
 
 var (
-	file        = flag.String("f", "", "the path to the file to outline")
-	importsOnly = flag.Bool("imports-only", false, "parse imports only")
-	modified    = flag.Bool("modified", false, "read an archive of the modified file from standard input")
+	file         = flag.String("f", "", "the path to the file to outline")
+	dir          = flag.String("dir", "", "the path to a directory to outline as a single package (mutually exclusive with -f)")
+	importsOnly  = flag.Bool("imports-only", false, "parse imports only")
+	modified     = flag.Bool("modified", false, "read an archive of the modified file(s) from standard input")
+	outputFormat = flag.String("format", "json", "output format: json (a single buffered tree) or jsonl/ndjson (stream one declaration per line as it's discovered)")
+	batch        = flag.Bool("batch", false, "read NUL-delimited file paths from standard input and outline each one in turn")
+	tags         = flag.String("tags", "", "comma-separated build tags to honor (with -dir, and with the multi-file -modified protocol) when deciding which files apply")
 )
 
 func main() {
 	flag.Parse()
+
+	if *dir != "" && *file != "" {
+		reportError(fmt.Errorf("-dir and -f are mutually exclusive"))
+		return
+	}
+	if *batch && (*file != "" || *dir != "") {
+		reportError(fmt.Errorf("-batch reads its own file list from stdin and is mutually exclusive with -f and -dir"))
+		return
+	}
+
 	fset := token.NewFileSet()
 	parserMode := parser.ParseComments
 	if *importsOnly {
 		parserMode = parser.ImportsOnly
 	}
 
-	var fileAst *ast.File
-	var err error
+	if *batch {
+		if err := runBatch(fset, parserMode); err != nil {
+			reportError(fmt.Errorf("batch outline failed: %v", err))
+		}
+		return
+	}
+
+	if *dir != "" {
+		pkg, errs, err := outlineDir(fset, *dir, parserMode)
+		if err != nil {
+			reportError(fmt.Errorf("could not outline directory %s: %v", *dir, err))
+		}
 
+		emitOutput(pkg, errs)
+		return
+	}
+
+	if *modified && *file == "" {
+		results, err := outlineOverlay(fset, parserMode)
+		if err != nil {
+			reportError(fmt.Errorf("could not outline -modified archive: %v", err))
+		}
+
+		fmt.Println(json.Marshal(results).String())
+		return
+	}
+
+	var src interface{}
 	if *modified {
 		archive, err := buildutil.ParseOverlayArchive(os.Stdin)
 		if err != nil {
@@ -49,15 +101,236 @@ func main() {
 		if !ok {
 			reportError(fmt.Errorf("couldn't find %s in archive", *file))
 		}
-		fileAst, err = parser.ParseFile(fset, *file, fc, parserMode)
-	} else {
-		fileAst, err = parser.ParseFile(fset, *file, nil, parserMode)
+		src = fc
+	}
+
+	fileAst, errs := parseFileReporting(fset, *file, src, parserMode)
+	if fileAst == nil {
+		emitOutput(nil, errs)
+		return
+	}
+
+	pkg := newDeclaration(fset, fileAst.Name.String(), "package", "", *file,
+		commentGroupText(fileAst.Doc), fileAst.Pos(), fileAst.End(), declsFromFile(fset, fileAst, *file))
+
+	emitOutput(&pkg, errs)
+}
+
+// parseFileReporting parses filename the same way parser.ParseFile does, but
+// treats a scanner.ErrorList as recoverable: it still returns the partial
+// AST parser.AllErrors produced, alongside the offending positions as
+// outlineErrors, instead of forcing the caller to abandon the outline. Any
+// other error (e.g. the file can't be read at all) yields a nil *ast.File.
+func parseFileReporting(fset *token.FileSet, filename string, src interface{}, mode parser.Mode) (*ast.File, []outlineError) {
+	fileAst, err := parser.ParseFile(fset, filename, src, mode|parser.AllErrors)
+	if err == nil {
+		return fileAst, nil
+	}
+
+	errList, ok := err.(scanner.ErrorList)
+	if !ok {
+		reportError(fmt.Errorf("could not parse file %s: %v", filename, err))
+		return nil, []outlineError{{File: filename, Msg: err.Error()}}
+	}
+
+	errs := make([]outlineError, len(errList))
+	for i, e := range errList {
+		errs[i] = outlineError{File: e.Pos.Filename, Line: e.Pos.Line, Column: e.Pos.Column, Msg: e.Msg}
 	}
 
+	return fileAst, errs
+}
+
+// buildContext returns the go/build.Context used to decide which files a
+// //go:build constraint lets through, seeded with the tags from -tags.
+func buildContext() build.Context {
+	bctx := build.Default
+	if *tags != "" {
+		bctx.BuildTags = strings.Split(*tags, ",")
+	}
+
+	return bctx
+}
+
+// outlineOverlay implements the multi-file -modified protocol: rather than
+// a single archive entry for -f, the archive on stdin may describe many
+// files at once. Each one is parsed and outlined independently, honoring
+// //go:build constraints via buildContext, and the result is a JSON object
+// keyed by filename.
+func outlineOverlay(fset *token.FileSet, mode parser.Mode) (map[string]outlineResult, error) {
+	archive, err := buildutil.ParseOverlayArchive(os.Stdin)
 	if err != nil {
-		reportError(fmt.Errorf("could not parse file %s", *file))
+		return nil, fmt.Errorf("failed to parse -modified archive: %v", err)
+	}
+
+	bctx := buildContext()
+	octx := buildutil.OverlayContext(&bctx, archive)
+	results := map[string]outlineResult{}
+
+	for name, src := range archive {
+		match, err := octx.MatchFile(filepath.Dir(name), filepath.Base(name))
+		if err != nil {
+			results[name] = outlineResult{Declarations: []*Declaration{}, Errors: []outlineError{{File: name, Msg: err.Error()}}}
+			continue
+		}
+		if !match {
+			continue
+		}
+
+		fileAst, errs := parseFileReporting(fset, name, src, mode)
+		declarations := []*Declaration{}
+		if fileAst != nil {
+			pkg := newDeclaration(fset, fileAst.Name.String(), "package", "", name,
+				commentGroupText(fileAst.Doc), fileAst.Pos(), fileAst.End(), declsFromFile(fset, fileAst, name))
+			declarations = append(declarations, &pkg)
+		}
+
+		results[name] = outlineResult{Declarations: declarations, Errors: errs}
+	}
+
+	return results, nil
+}
+
+// runBatch reads NUL-delimited file paths from stdin and outlines each one
+// in turn against a shared FileSet.
+func runBatch(fset *token.FileSet, mode parser.Mode) error {
+	reader := bufio.NewReader(os.Stdin)
+
+	for {
+		name, err := reader.ReadString(0)
+		name = strings.TrimSuffix(name, "\x00")
+		if name != "" {
+			outlineBatchFile(fset, mode, name)
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func outlineBatchFile(fset *token.FileSet, mode parser.Mode, name string) {
+	fileAst, errs := parseFileReporting(fset, name, nil, mode)
+	if fileAst == nil {
+		emitOutput(nil, errs)
+		return
+	}
+
+	pkg := newDeclaration(fset, fileAst.Name.String(), "package", "", name,
+		commentGroupText(fileAst.Doc), fileAst.Pos(), fileAst.End(), declsFromFile(fset, fileAst, name))
+
+	emitOutput(&pkg, errs)
+}
+
+// outlineError is a structured parse error: a broken-in-progress file still
+// yields a partial outline, with the offending positions reported alongside
+// it instead of aborting the whole invocation.
+type outlineError struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	Msg    string `json:"msg"`
+}
+
+// outlineResult is the top-level shape of -format=json output: the outlined
+// package(s), plus any parse errors encountered along the way.
+type outlineResult struct {
+	Declarations []*Declaration `json:"declarations"`
+	Errors       []outlineError `json:"errors,omitempty"`
+}
+
+// emitOutput writes pkg in the format selected by -format: a single
+// buffered JSON tree, or a stream of jsonl/ndjson records. pkg may be nil
+// if the file couldn't be parsed at all, in which case only errs is emitted.
+func emitOutput(pkg *Declaration, errs []outlineError) {
+	if *outputFormat == "jsonl" || *outputFormat == "ndjson" {
+		writeJSONL(os.Stdout, pkg, errs)
+		return
+	}
+
+	declarations := []*Declaration{}
+	if pkg != nil {
+		declarations = append(declarations, pkg)
+	}
+
+	fmt.Println(json.Marshal(outlineResult{Declarations: declarations, Errors: errs}).String())
+}
+
+// jsonlRecord is one line of -format=jsonl/ndjson output: a Declaration's
+// fields plus the ids needed to reconstruct the tree without buffering it.
+// The fields are copied out of Declaration rather than embedded, because
+// Declaration is self-referential through Children and the JSON encoder's
+// recursive-type compiler panics on a struct that anonymously embeds one
+// (Children is always nil here anyway, since each child gets its own line).
+type jsonlRecord struct {
+	ID           int       `json:"id"`
+	Parent       int       `json:"parent"`
+	Label        string    `json:"label"`
+	Type         string    `json:"type"`
+	ReceiverType string    `json:"receiverType,omitempty"`
+	File         string    `json:"file,omitempty"`
+	Doc          string    `json:"doc,omitempty"`
+	Start        token.Pos `json:"start"`
+	End          token.Pos `json:"end"`
+	StartLine    int       `json:"startLine"`
+	StartCol     int       `json:"startCol"`
+	EndLine      int       `json:"endLine"`
+	EndCol       int       `json:"endCol"`
+}
+
+// writeJSONL streams pkg and its descendants as one JSON object per line,
+// each tagged with an id and its parent's id (-1 for the root), followed by
+// one line per entry in errs, so a long-lived caller can start rendering
+// the outline before the whole file has been walked. pkg may be nil if the
+// file couldn't be parsed at all.
+func writeJSONL(w io.Writer, pkg *Declaration, errs []outlineError) {
+	for _, e := range errs {
+		fmt.Fprintln(w, json.Marshal(e).String())
+	}
+
+	if pkg == nil {
+		return
+	}
+
+	nextID := 0
+
+	var emit func(d *Declaration, parent int)
+	emit = func(d *Declaration, parent int) {
+		id := nextID
+		nextID++
+
+		record := jsonlRecord{
+			ID:           id,
+			Parent:       parent,
+			Label:        d.Label,
+			Type:         d.Type,
+			ReceiverType: d.ReceiverType,
+			File:         d.File,
+			Doc:          d.Doc,
+			Start:        d.Start,
+			End:          d.End,
+			StartLine:    d.StartLine,
+			StartCol:     d.StartCol,
+			EndLine:      d.EndLine,
+			EndCol:       d.EndCol,
+		}
+		fmt.Fprintln(w, json.Marshal(record).String())
+
+		for i := range d.Children {
+			emit(&d.Children[i], id)
+		}
 	}
 
+	emit(pkg, -1)
+}
+
+// declsFromFile walks the top-level declarations of fileAst and returns a
+// Declaration for each, tagging every one with the file it came from so
+// callers that aggregate several files (see outlineDir) can tell them apart.
+func declsFromFile(fset *token.FileSet, fileAst *ast.File, filename string) []Declaration {
+	cmap := ast.NewCommentMap(fset, fileAst, fileAst.Comments)
 	declarations := []Declaration{}
 
 	for _, decl := range fileAst.Decls {
@@ -67,50 +340,29 @@ func main() {
 			if err != nil {
 				reportError(fmt.Errorf("failed to parse receiver type: %v", err))
 			}
-			declarations = append(declarations, Declaration{
-				Label:        decl.Name.String(),
-				Type:         "function",
-				ReceiverType: receiverType,
-				Start:        decl.Pos(),
-				End:          decl.End(),
-				Children:     []Declaration{},
-			})
+			declarations = append(declarations, newDeclaration(fset, decl.Name.String(), "function", receiverType,
+				filename, docText(cmap, decl), decl.Pos(), decl.End(), []Declaration{}))
 		case *ast.GenDecl:
 			for _, spec := range decl.Specs {
 				switch spec := spec.(type) {
 				case *ast.ImportSpec:
-					declarations = append(declarations, Declaration{
-						Label:        spec.Path.Value,
-						Type:         "import",
-						ReceiverType: "",
-						Start:        spec.Pos(),
-						End:          spec.End(),
-						Children:     []Declaration{},
-					})
+					declarations = append(declarations, newDeclaration(fset, spec.Path.Value, "import", "",
+						filename, docText(cmap, spec), spec.Pos(), spec.End(), []Declaration{}))
 				case *ast.TypeSpec:
-					// TODO: Members if it's a struct or interface type?
-					declarations = append(declarations, Declaration{
-						Label:        spec.Name.String(),
-						Type:         "type",
-						ReceiverType: "",
-						Start:        spec.Pos(),
-						End:          spec.End(),
-						Children:     []Declaration{},
-					})
+					children, err := getTypeSpecChildren(fset, cmap, spec)
+					if err != nil {
+						reportError(fmt.Errorf("failed to parse members of %s: %v", spec.Name.String(), err))
+					}
+					declarations = append(declarations, newDeclaration(fset, spec.Name.String(), "type", "",
+						filename, docText(cmap, spec), spec.Pos(), spec.End(), children))
 				case *ast.ValueSpec:
+					varOrConst := "variable"
+					if decl.Tok == token.CONST {
+						varOrConst = "constant"
+					}
 					for _, id := range spec.Names {
-						varOrConst := "variable"
-						if decl.Tok == token.CONST {
-							varOrConst = "constant"
-						}
-						declarations = append(declarations, Declaration{
-							Label:        id.Name,
-							Type:         varOrConst,
-							ReceiverType: "",
-							Start:        id.Pos(),
-							End:          id.End(),
-							Children:     []Declaration{},
-						})
+						declarations = append(declarations, newDeclaration(fset, id.Name, varOrConst, "",
+							filename, docText(cmap, spec), id.Pos(), id.End(), []Declaration{}))
 					}
 				default:
 					reportError(fmt.Errorf("unknown token type: %s", decl.Tok))
@@ -121,16 +373,146 @@ func main() {
 		}
 	}
 
-	pkg := []*Declaration{{
-		Label:        fileAst.Name.String(),
-		Type:         "package",
-		ReceiverType: "",
-		Start:        fileAst.Pos(),
-		End:          fileAst.End(),
-		Children:     declarations,
-	}}
+	return declarations
+}
+
+// newDeclaration builds a Declaration, resolving start/end line and column
+// from fset.
+func newDeclaration(fset *token.FileSet, label, typ, receiverType, file, doc string, start, end token.Pos, children []Declaration) Declaration {
+	startPos := fset.Position(start)
+	endPos := fset.Position(end)
+
+	return Declaration{
+		Label:        label,
+		Type:         typ,
+		ReceiverType: receiverType,
+		File:         file,
+		Doc:          doc,
+		Start:        start,
+		End:          end,
+		StartLine:    startPos.Line,
+		StartCol:     startPos.Column,
+		EndLine:      endPos.Line,
+		EndCol:       endPos.Column,
+		Children:     children,
+	}
+}
+
+// docText returns the trimmed lead doc comment the CommentMap associated
+// with node, or "" if it has none.
+func docText(cmap ast.CommentMap, node ast.Node) string {
+	groups := cmap[node]
+	if len(groups) == 0 {
+		return ""
+	}
+
+	texts := make([]string, len(groups))
+	for i, group := range groups {
+		texts[i] = group.Text()
+	}
+
+	return strings.TrimSpace(strings.Join(texts, ""))
+}
+
+// commentGroupText returns the trimmed text of cg, or "" if cg is nil.
+func commentGroupText(cg *ast.CommentGroup) string {
+	if cg == nil {
+		return ""
+	}
+
+	return strings.TrimSpace(cg.Text())
+}
+
+// outlineDir walks every .go file directly inside dirPath, honoring build
+// tags via go/build.Context, and aggregates their declarations under one
+// synthetic package node. When -modified is set, the file contents in the
+// overlay archive on stdin take precedence over what's on disk, so editors
+// can send unsaved buffers for a whole directory in one call. A file that
+// exists only in the overlay (a new, as-yet-unsaved buffer) is never visited
+// by WalkDir, so it's outlined separately once the walk is done.
+func outlineDir(fset *token.FileSet, dirPath string, mode parser.Mode) (*Declaration, []outlineError, error) {
+	dirPath = filepath.Clean(dirPath)
+
+	overlay := map[string][]byte{}
+	if *modified {
+		archive, err := buildutil.ParseOverlayArchive(os.Stdin)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse -modified archive: %v", err)
+		}
+		overlay = archive
+	}
+
+	bctx := buildContext()
+	octx := buildutil.OverlayContext(&bctx, overlay)
+	pkgName := ""
+	declarations := []Declaration{}
+	var errs []outlineError
+	visited := map[string]bool{}
+
+	outlineFile := func(path string, name string) {
+		match, matchErr := octx.MatchFile(filepath.Dir(path), name)
+		if matchErr != nil {
+			errs = append(errs, outlineError{File: path, Msg: matchErr.Error()})
+			return
+		}
+		if !match {
+			return
+		}
+
+		src, ok := overlay[path]
+		if !ok {
+			var err error
+			src, err = os.ReadFile(path)
+			if err != nil {
+				errs = append(errs, outlineError{File: path, Msg: err.Error()})
+				return
+			}
+		}
+
+		fileAst, fileErrs := parseFileReporting(fset, path, src, mode)
+		errs = append(errs, fileErrs...)
+		if fileAst == nil {
+			return
+		}
+
+		if pkgName == "" {
+			pkgName = fileAst.Name.String()
+		}
+
+		declarations = append(declarations, declsFromFile(fset, fileAst, path)...)
+	}
+
+	err := filepath.WalkDir(dirPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dirPath {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if filepath.Ext(path) != ".go" {
+			return nil
+		}
+
+		visited[path] = true
+		outlineFile(path, d.Name())
+		return nil
+	})
+	if err != nil {
+		return nil, errs, err
+	}
+
+	for name := range overlay {
+		if visited[name] || filepath.Ext(name) != ".go" || filepath.Dir(name) != dirPath {
+			continue
+		}
+		outlineFile(name, filepath.Base(name))
+	}
 
-	fmt.Println(json.Marshal(pkg).String())
+	pkg := newDeclaration(fset, pkgName, "package", "", "", "", token.NoPos, token.NoPos, declarations)
+	return &pkg, errs, nil
 }
 
 func getReceiverType(fset *token.FileSet, decl *ast.FuncDecl) (string, error) {
@@ -138,14 +520,61 @@ func getReceiverType(fset *token.FileSet, decl *ast.FuncDecl) (string, error) {
 		return "", nil
 	}
 
+	return renderType(fset, decl.Recv.List[0].Type)
+}
+
+func renderType(fset *token.FileSet, expr ast.Expr) (string, error) {
 	buf := &bytes.Buffer{}
-	if err := format.Node(buf, fset, decl.Recv.List[0].Type); err != nil {
+	if err := format.Node(buf, fset, expr); err != nil {
 		return "", err
 	}
 
 	return buf.String(), nil
 }
 
+// getTypeSpecChildren walks the struct fields or interface methods named by
+// spec and returns a Declaration for each member.
+func getTypeSpecChildren(fset *token.FileSet, cmap ast.CommentMap, spec *ast.TypeSpec) ([]Declaration, error) {
+	switch t := spec.Type.(type) {
+	case *ast.StructType:
+		return getFieldListChildren(fset, cmap, t.Fields, "field")
+	case *ast.InterfaceType:
+		return getFieldListChildren(fset, cmap, t.Methods, "method")
+	default:
+		return []Declaration{}, nil
+	}
+}
+
+func getFieldListChildren(fset *token.FileSet, cmap ast.CommentMap, fields *ast.FieldList, memberType string) ([]Declaration, error) {
+	children := []Declaration{}
+	if fields == nil {
+		return children, nil
+	}
+
+	for _, field := range fields.List {
+		renderedType, err := renderType(fset, field.Type)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := docText(cmap, field)
+
+		if len(field.Names) == 0 {
+			// Embedded field or embedded interface: the type name is the member name.
+			children = append(children, newDeclaration(fset, renderedType, memberType, "", "",
+				doc, field.Pos(), field.End(), []Declaration{}))
+			continue
+		}
+
+		for _, name := range field.Names {
+			children = append(children, newDeclaration(fset, name.String(), memberType, renderedType, "",
+				doc, field.Pos(), field.End(), []Declaration{}))
+		}
+	}
+
+	return children, nil
+}
+
 func reportError(err error) {
 	fmt.Fprintln(os.Stderr, "error:", err)
 }
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func buildOverlayArchive(files map[string]string) []byte {
+	var buf bytes.Buffer
+	for name, content := range files {
+		fmt.Fprintf(&buf, "%s\n%d\n%s", name, len(content), content)
+	}
+	return buf.Bytes()
+}
+
+func withStdin(t *testing.T, data []byte, fn func()) {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("os.Pipe: %v", err)
+	}
+	prevStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = prevStdin }()
+
+	go func() {
+		w.Write(data)
+		w.Close()
+	}()
+
+	fn()
+}
+
+func TestOutlineOverlay_RespectsBuildTags(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+	pathA := write("a.go", "package pkg\n")
+	pathB := write("b.go", "//go:build ignore\n\npackage pkg\n")
+	// pathC and pathD exist only in the overlay, never written to disk, to
+	// cover the editor-sends-an-unsaved-buffer case: MatchFile must consult
+	// the overlay content instead of failing to open a nonexistent file.
+	pathC := filepath.Join(dir, "c.go")
+	pathD := filepath.Join(dir, "d.go")
+
+	archive := buildOverlayArchive(map[string]string{
+		pathA: "package pkg\n\nfunc A() {}\n",
+		pathB: "//go:build ignore\n\npackage pkg\n\nfunc B() {}\n",
+		pathC: "package pkg\n\nfunc C() {}\n",
+		pathD: "//go:build ignore\n\npackage pkg\n\nfunc D() {}\n",
+	})
+
+	prevTags := *tags
+	*tags = ""
+	defer func() { *tags = prevTags }()
+
+	var results map[string]outlineResult
+	withStdin(t, archive, func() {
+		fset := token.NewFileSet()
+		var err error
+		results, err = outlineOverlay(fset, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("outlineOverlay: %v", err)
+		}
+	})
+
+	if _, ok := results[pathA]; !ok {
+		t.Errorf("expected %s in results, got %v", pathA, results)
+	}
+	if _, ok := results[pathB]; ok {
+		t.Errorf("expected %s to be excluded by its go:build ignore constraint, got %v", pathB, results)
+	}
+	if _, ok := results[pathC]; !ok {
+		t.Errorf("expected overlay-only %s in results, got %v", pathC, results)
+	}
+	if _, ok := results[pathD]; ok {
+		t.Errorf("expected overlay-only %s to be excluded by its go:build ignore constraint, got %v", pathD, results)
+	}
+}
+
+func TestOutlineOverlay_ReportsParseErrors(t *testing.T) {
+	archive := buildOverlayArchive(map[string]string{
+		"/nonexistent-dir-for-test/broken.go": "package pkg\n\nfunc F(\n",
+	})
+
+	var results map[string]outlineResult
+	withStdin(t, archive, func() {
+		fset := token.NewFileSet()
+		var err error
+		results, err = outlineOverlay(fset, parser.ParseComments)
+		if err != nil {
+			t.Fatalf("outlineOverlay: %v", err)
+		}
+	})
+
+	result, ok := results["/nonexistent-dir-for-test/broken.go"]
+	if !ok {
+		t.Fatalf("expected a result entry reporting the parse error, got %v", results)
+	}
+	if len(result.Errors) == 0 {
+		t.Fatalf("expected at least one error, got %+v", result.Errors)
+	}
+}
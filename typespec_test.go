@@ -0,0 +1,113 @@
+package main
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"testing"
+)
+
+const typeSpecTestSrc = `package p
+
+import "io"
+
+type S struct {
+	// Name is the display name.
+	Name string
+	Age  int
+	io.Reader
+}
+
+type I interface {
+	// Do runs the operation.
+	Do(x int) error
+	io.Closer
+}
+`
+
+func findTypeSpec(f *ast.File, name string) *ast.TypeSpec {
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if ok && typeSpec.Name.Name == name {
+				return typeSpec
+			}
+		}
+	}
+	return nil
+}
+
+func TestGetTypeSpecChildren_Struct(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.go", typeSpecTestSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	cmap := ast.NewCommentMap(fset, f, f.Comments)
+
+	spec := findTypeSpec(f, "S")
+	if spec == nil {
+		t.Fatal("type S not found")
+	}
+
+	children, err := getTypeSpecChildren(fset, cmap, spec)
+	if err != nil {
+		t.Fatalf("getTypeSpecChildren: %v", err)
+	}
+	if len(children) != 3 {
+		t.Fatalf("expected 3 fields, got %d: %+v", len(children), children)
+	}
+
+	name, age, embedded := children[0], children[1], children[2]
+
+	if name.Label != "Name" || name.Type != "field" || name.ReceiverType != "string" {
+		t.Errorf("unexpected Name field: %+v", name)
+	}
+	if name.Doc != "Name is the display name." {
+		t.Errorf("Name doc = %q, want %q", name.Doc, "Name is the display name.")
+	}
+	if age.Label != "Age" || age.ReceiverType != "int" {
+		t.Errorf("unexpected Age field: %+v", age)
+	}
+	if embedded.Label != "io.Reader" || embedded.ReceiverType != "" {
+		t.Errorf("unexpected embedded field: %+v", embedded)
+	}
+}
+
+func TestGetTypeSpecChildren_Interface(t *testing.T) {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "test.go", typeSpecTestSrc, parser.ParseComments)
+	if err != nil {
+		t.Fatalf("ParseFile: %v", err)
+	}
+	cmap := ast.NewCommentMap(fset, f, f.Comments)
+
+	spec := findTypeSpec(f, "I")
+	if spec == nil {
+		t.Fatal("type I not found")
+	}
+
+	children, err := getTypeSpecChildren(fset, cmap, spec)
+	if err != nil {
+		t.Fatalf("getTypeSpecChildren: %v", err)
+	}
+	if len(children) != 2 {
+		t.Fatalf("expected 2 methods, got %d: %+v", len(children), children)
+	}
+
+	do, embedded := children[0], children[1]
+
+	if do.Label != "Do" || do.Type != "method" || do.ReceiverType != "func(x int) error" {
+		t.Errorf("unexpected Do method: %+v", do)
+	}
+	if do.Doc != "Do runs the operation." {
+		t.Errorf("Do doc = %q, want %q", do.Doc, "Do runs the operation.")
+	}
+	if embedded.Label != "io.Closer" || embedded.Type != "method" {
+		t.Errorf("unexpected embedded method: %+v", embedded)
+	}
+}